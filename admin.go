@@ -0,0 +1,157 @@
+package dashing
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// requireAdmin protects an admin handler with HTTP Basic Auth, if
+// credentials were configured with WithAdmin, falling back to the JWT
+// scheme configured with WithAuth: a token must cover the
+// "admin/status" topic in its publish claim.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminUser != "" {
+			user, pass, ok := r.BasicAuth()
+			if ok && constantTimeEqual(user, s.adminUser) && constantTimeEqual(pass, s.adminPass) {
+				h(w, r)
+				return
+			}
+		} else if s.auth != nil {
+			if err := s.auth.AuthorizePublish(r, "admin", "status"); err == nil {
+				h(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", `Basic realm="dashing admin"`)
+		http.Error(w, "", http.StatusUnauthorized)
+	}
+}
+
+// constantTimeEqual reports whether a and b are equal without leaking
+// their length of common prefix through timing, unlike ==.
+func constantTimeEqual(a, b string) bool {
+	return len(a) == len(b) && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// AdminJobsHandler reports every registered job's last-run bookkeeping.
+func (s *Server) AdminJobsHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.worker.Status())
+}
+
+// AdminTriggerHandler runs the TriggerableJob named by :id once,
+// out-of-band from any schedule it has.
+func (s *Server) AdminTriggerHandler(w http.ResponseWriter, r *http.Request) {
+	if err := s.worker.Trigger(param(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// AdminClientsHandler reports every currently connected SSE client.
+func (s *Server) AdminClientsHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.broker.Stats().Clients)
+}
+
+// AdminEventsHandler reports event delivery rates per ID over the
+// last 1, 5 and 15 minutes.
+func (s *Server) AdminEventsHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.broker.Stats().EventRates)
+}
+
+// AdminMetricsHandler exposes the same job, client and event counters
+// as the JSON admin endpoints in Prometheus text exposition format.
+func (s *Server) AdminMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	stats := s.broker.Stats()
+
+	fmt.Fprintln(w, "# HELP dashing_job_last_run_seconds Unix timestamp of each job's last run.")
+	fmt.Fprintln(w, "# TYPE dashing_job_last_run_seconds gauge")
+	for _, job := range s.worker.Status() {
+		fmt.Fprintf(w, "dashing_job_last_run_seconds{job=%q} %d\n", job.Name, job.LastRun.Unix())
+	}
+
+	fmt.Fprintln(w, "# HELP dashing_job_errors_total Whether each job has recorded an error (1) or not (0).")
+	fmt.Fprintln(w, "# TYPE dashing_job_errors_total gauge")
+	for _, job := range s.worker.Status() {
+		errored := 0
+		if job.LastError != nil {
+			errored = 1
+		}
+		fmt.Fprintf(w, "dashing_job_errors_total{job=%q} %d\n", job.Name, errored)
+	}
+
+	fmt.Fprintln(w, "# HELP dashing_broker_clients Number of connected SSE clients.")
+	fmt.Fprintln(w, "# TYPE dashing_broker_clients gauge")
+	fmt.Fprintf(w, "dashing_broker_clients %d\n", len(stats.Clients))
+
+	fmt.Fprintln(w, "# HELP dashing_broker_queue_depth Number of events queued in the broker.")
+	fmt.Fprintln(w, "# TYPE dashing_broker_queue_depth gauge")
+	fmt.Fprintf(w, "dashing_broker_queue_depth %d\n", stats.QueueDepth)
+
+	fmt.Fprintln(w, "# HELP dashing_event_rate Number of times an event ID was broadcast within a window.")
+	fmt.Fprintln(w, "# TYPE dashing_event_rate gauge")
+	for _, rate := range stats.EventRates {
+		fmt.Fprintf(w, "dashing_event_rate{id=%q,window=\"1m\"} %d\n", rate.ID, rate.Last1m)
+		fmt.Fprintf(w, "dashing_event_rate{id=%q,window=\"5m\"} %d\n", rate.ID, rate.Last5m)
+		fmt.Fprintf(w, "dashing_event_rate{id=%q,window=\"15m\"} %d\n", rate.ID, rate.Last15m)
+	}
+}
+
+var adminStatusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><title>dashing status</title></head>
+<body>
+<h1>Jobs</h1>
+<table border="1">
+<tr><th>Name</th><th>Last run</th><th>Last error</th><th>Last payload</th></tr>
+{{range .Jobs}}<tr><td>{{.Name}}</td><td>{{.LastRun}}</td><td>{{.LastError}}</td><td>{{.LastPayload}}</td></tr>
+{{end}}</table>
+
+<h1>Clients</h1>
+<table border="1">
+<tr><th>Remote addr</th><th>Topics</th><th>Age</th><th>Delivered</th></tr>
+{{range .Clients}}<tr><td>{{.RemoteAddr}}</td><td>{{.Topics}}</td><td>{{.Age}}</td><td>{{.Delivered}}</td></tr>
+{{end}}</table>
+
+<h1>Event rates</h1>
+<table border="1">
+<tr><th>ID</th><th>1m</th><th>5m</th><th>15m</th></tr>
+{{range .EventRates}}<tr><td>{{.ID}}</td><td>{{.Last1m}}</td><td>{{.Last5m}}</td><td>{{.Last15m}}</td></tr>
+{{end}}</table>
+
+<p>Broker queue depth: {{.QueueDepth}}</p>
+</body>
+</html>
+`))
+
+type adminClientView struct {
+	ClientStats
+	Age time.Duration
+}
+
+// AdminStatusHandler renders the HTML status page summarizing jobs,
+// connected clients and event throughput.
+func (s *Server) AdminStatusHandler(w http.ResponseWriter, r *http.Request) {
+	stats := s.broker.Stats()
+
+	clients := make([]adminClientView, 0, len(stats.Clients))
+	for _, c := range stats.Clients {
+		clients = append(clients, adminClientView{ClientStats: c, Age: time.Since(c.ConnectedAt)})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	adminStatusTemplate.Execute(w, map[string]interface{}{
+		"Jobs":       s.worker.Status(),
+		"Clients":    clients,
+		"EventRates": stats.EventRates,
+		"QueueDepth": stats.QueueDepth,
+	})
+}
@@ -0,0 +1,133 @@
+package dashing
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// ErrUnauthorized is returned when a request carries no usable
+// credentials (missing, malformed or expired token) and anonymous
+// access isn't allowed for what's being requested.
+var ErrUnauthorized = errors.New("dashing: missing or invalid token")
+
+// ErrForbidden is returned when a request's token is valid but its
+// selectors don't cover what's being requested.
+var ErrForbidden = errors.New("dashing: token does not cover this topic")
+
+// Authorizer validates bearer tokens on /events and the dashboard and
+// widget publish endpoints, and reports which topic selectors a
+// request's token grants for subscribing and publishing. It follows
+// the hub model used by Mercure: a token's claims, read from a
+// configurable namespace, carry "subscribe" and "publish" arrays of
+// topic selectors.
+type Authorizer struct {
+	// Keys holds the signing key(s) accepted for tokens: a []byte for
+	// HS256, or a *rsa.PublicKey for RS256. When more than one key is
+	// configured, tokens are matched to a key by their "kid" header.
+	Keys map[string]interface{}
+
+	// Namespace is the claim key "subscribe" and "publish" selector
+	// arrays are read from, e.g. {"dashing": {"subscribe": [...]}}.
+	// Defaults to "dashing".
+	Namespace string
+
+	// AnonymousSubscribe lists the selectors unauthenticated clients
+	// may subscribe to. Leave nil to require a token for every
+	// subscription. Anonymous clients may never publish.
+	AnonymousSubscribe []string
+}
+
+type tokenClaims struct {
+	Subscribe []string
+	Publish   []string
+}
+
+func (a *Authorizer) namespace() string {
+	if a.Namespace == "" {
+		return "dashing"
+	}
+	return a.Namespace
+}
+
+func (a *Authorizer) parse(r *http.Request) (*tokenClaims, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		if len(a.AnonymousSubscribe) == 0 {
+			return nil, ErrUnauthorized
+		}
+		return &tokenClaims{Subscribe: a.AnonymousSubscribe}, nil
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return nil, ErrUnauthorized
+	}
+
+	token, err := jwt.Parse(parts[1], func(t *jwt.Token) (interface{}, error) {
+		if len(a.Keys) == 1 {
+			for _, key := range a.Keys {
+				return key, nil
+			}
+		}
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := a.Keys[kid]; ok {
+			return key, nil
+		}
+		return nil, ErrUnauthorized
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrUnauthorized
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+
+	ns, _ := claims[a.namespace()].(map[string]interface{})
+	return &tokenClaims{
+		Subscribe: stringSlice(ns["subscribe"]),
+		Publish:   stringSlice(ns["publish"]),
+	}, nil
+}
+
+func stringSlice(v interface{}) []string {
+	raw, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+	out := make([]string, 0, len(raw))
+	for _, s := range raw {
+		if str, ok := s.(string); ok {
+			out = append(out, str)
+		}
+	}
+	return out
+}
+
+// Subscribed returns the topic selectors the request's token grants
+// for subscription. It returns ErrUnauthorized if the request has no
+// usable token and anonymous subscription isn't configured.
+func (a *Authorizer) Subscribed(r *http.Request) ([]string, error) {
+	claims, err := a.parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Subscribe, nil
+}
+
+// AuthorizePublish reports whether the request's token permits
+// publishing to the given target/id topic.
+func (a *Authorizer) AuthorizePublish(r *http.Request, target, id string) error {
+	claims, err := a.parse(r)
+	if err != nil {
+		return err
+	}
+	if !matchesAny(claims.Publish, eventTopic(target, id)) {
+		return ErrForbidden
+	}
+	return nil
+}
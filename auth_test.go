@@ -0,0 +1,111 @@
+package dashing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+var testKey = []byte("test-signing-key")
+
+func signToken(t *testing.T, subscribe, publish []string, expiresIn time.Duration) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"dashing": map[string]interface{}{
+			"subscribe": toInterfaceSlice(subscribe),
+			"publish":   toInterfaceSlice(publish),
+		},
+	}
+	if expiresIn != 0 {
+		claims["exp"] = time.Now().Add(expiresIn).Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(testKey)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func toInterfaceSlice(s []string) []interface{} {
+	out := make([]interface{}, len(s))
+	for i, v := range s {
+		out[i] = v
+	}
+	return out
+}
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest("GET", "/events", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthorizePublishRejectsUncoveredTopic(t *testing.T) {
+	auth := &Authorizer{Keys: map[string]interface{}{"": testKey}}
+	token := signToken(t, nil, []string{"widgets/allowed"}, time.Hour)
+
+	if err := auth.AuthorizePublish(bearerRequest(token), "", "other"); err != ErrForbidden {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if err := auth.AuthorizePublish(bearerRequest(token), "", "allowed"); err != nil {
+		t.Fatalf("expected covered topic to be authorized, got %v", err)
+	}
+}
+
+func TestSubscribedFiltersDelivery(t *testing.T) {
+	auth := &Authorizer{Keys: map[string]interface{}{"": testKey}}
+	token := signToken(t, []string{"dashboards/{id}"}, nil, time.Hour)
+
+	selectors, err := auth.Subscribed(bearerRequest(token))
+	if err != nil {
+		t.Fatalf("Subscribed: %v", err)
+	}
+
+	if !matchesAny(selectors, eventTopic("dashboards", "ops")) {
+		t.Error("expected dashboards/ops to be delivered")
+	}
+	if matchesAny(selectors, eventTopic("", "ops")) {
+		t.Error("expected widgets/ops to be filtered out")
+	}
+}
+
+func TestExpiredTokenRejected(t *testing.T) {
+	auth := &Authorizer{Keys: map[string]interface{}{"": testKey}}
+	token := signToken(t, []string{"*"}, []string{"*"}, -time.Hour)
+
+	if _, err := auth.Subscribed(bearerRequest(token)); err != ErrUnauthorized {
+		t.Fatalf("expected ErrUnauthorized for expired token, got %v", err)
+	}
+}
+
+func TestAnonymousSubscribeWithoutToken(t *testing.T) {
+	auth := &Authorizer{AnonymousSubscribe: []string{"dashboards/public"}}
+
+	selectors, err := auth.Subscribed(bearerRequest(""))
+	if err != nil {
+		t.Fatalf("expected anonymous subscription to be allowed, got %v", err)
+	}
+	if !matchesAny(selectors, eventTopic("dashboards", "public")) {
+		t.Error("expected dashboards/public to be allowed anonymously")
+	}
+
+	if err := auth.AuthorizePublish(bearerRequest(""), "dashboards", "public"); err == nil {
+		t.Error("expected anonymous publish to be rejected")
+	}
+}
+
+func TestMissingTokenRejectedWithoutAnonymous(t *testing.T) {
+	auth := &Authorizer{Keys: map[string]interface{}{"": testKey}}
+
+	if _, err := auth.Subscribed(bearerRequest("")); err != ErrUnauthorized {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+}
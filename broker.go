@@ -0,0 +1,403 @@
+package dashing
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultBufferSize is how many past events a Broker replays to a
+// client reconnecting with a Last-Event-ID, if not overridden with
+// WithBufferSize.
+const defaultBufferSize = 1000
+
+// clientSendBuffer is how many undelivered events a client channel
+// will queue before the broker considers it unresponsive.
+const clientSendBuffer = 16
+
+// forwarderSendBuffer is how many undelivered events a forwarder's
+// queue will hold before new events are dropped rather than blocking
+// the broker.
+const forwarderSendBuffer = 64
+
+// rateWindows are the lookback windows event rates are reported over
+// in BrokerStats.
+var rateWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// A Forwarder receives every event published to a Broker and relays it
+// to some external sink. Forward is called once per event; Close is
+// called when the broker shuts down.
+type Forwarder interface {
+	Forward(e *Event) error
+	Close()
+}
+
+// Global registry for forwarders, mirroring the Job registry in worker.go.
+var forwarders []Forwarder
+
+// RegisterForwarder adds a forwarder to every broker created afterwards.
+func RegisterForwarder(f Forwarder) {
+	if f == nil {
+		panic("Can't register nil forwarder")
+	}
+	forwarders = append(forwarders, f)
+}
+
+// forwarderWorker decouples a Forwarder's Forward calls from the
+// broker's single fan-out goroutine. Each forwarder gets its own
+// buffered queue and worker goroutine, so a slow or unreachable sink
+// (a wedged MQTT broker, a WebSocket write with no deadline) can't
+// stall event delivery to every other client and forwarder.
+type forwarderWorker struct {
+	forwarder Forwarder
+	events    chan *Event
+}
+
+func startForwarderWorker(f Forwarder) *forwarderWorker {
+	fw := &forwarderWorker{forwarder: f, events: make(chan *Event, forwarderSendBuffer)}
+	go fw.run()
+	return fw
+}
+
+func (fw *forwarderWorker) run() {
+	for event := range fw.events {
+		if err := fw.forwarder.Forward(event); err != nil {
+			log.Printf("dashing: forwarder error: %v", err)
+		}
+	}
+}
+
+// send enqueues event for delivery, dropping it if the forwarder is
+// too far behind to keep up rather than blocking the broker.
+func (fw *forwarderWorker) send(event *Event) {
+	select {
+	case fw.events <- event:
+	default:
+		log.Printf("dashing: forwarder queue full, dropping event %q", event.ID)
+	}
+}
+
+func (fw *forwarderWorker) close() {
+	close(fw.events)
+	fw.forwarder.Close()
+}
+
+// deliveredEvent pairs a broadcast event with the monotonic sequence
+// ID and timestamp it was broadcast under, so that an event replayed
+// after a reconnect keeps the identity and "updatedAt" it was first
+// delivered with. payload is the event's wire-format JSON, computed
+// once per broadcast rather than once per subscriber: event.Body is
+// shared with every SSE client goroutine and every forwarder, so
+// nothing downstream may mutate it to stamp "id"/"updatedAt" in.
+type deliveredEvent struct {
+	seqID     uint64
+	updatedAt int32
+	event     *Event
+	payload   []byte
+}
+
+// subscription is how EventsHandler registers a client with the
+// broker's single fan-out goroutine: it both adds the client and asks
+// for a replay of buffered events in one step, so no events can be
+// missed or duplicated around the registration.
+type subscription struct {
+	ch         chan *deliveredEvent
+	afterSeq   uint64
+	remoteAddr string
+	topics     []string
+	replay     chan []*deliveredEvent
+}
+
+// clientInfo tracks the admin-visible state of one connected SSE client.
+type clientInfo struct {
+	remoteAddr  string
+	topics      []string
+	connectedAt time.Time
+	delivered   uint64
+}
+
+// ClientStats is a point-in-time snapshot of a connected SSE client,
+// returned by Broker.Stats for the admin API.
+type ClientStats struct {
+	RemoteAddr  string
+	Topics      []string
+	ConnectedAt time.Time
+	Delivered   uint64
+}
+
+// EventRate is how often a given event ID was broadcast over each of
+// the last 1, 5 and 15 minutes, returned by Broker.Stats.
+type EventRate struct {
+	ID      string
+	Last1m  int
+	Last5m  int
+	Last15m int
+}
+
+// BrokerStats is a point-in-time snapshot of a Broker, for the admin API.
+type BrokerStats struct {
+	QueueDepth int
+	Clients    []ClientStats
+	EventRates []EventRate
+}
+
+// A Broker fans out events to connected SSE clients and to any
+// registered forwarders (MQTT, WebSocket, etc), and keeps a ring
+// buffer of recent events so reconnecting clients can replay what they
+// missed.
+type Broker struct {
+	newClients     chan *subscription
+	defunctClients chan chan *deliveredEvent
+	events         chan *Event
+	statsRequests  chan chan *BrokerStats
+
+	clients    map[chan *deliveredEvent]*clientInfo
+	forwarders []*forwarderWorker
+	mutex      sync.RWMutex
+
+	bufferSize int
+	buffer     []*deliveredEvent
+	nextSeq    uint64
+
+	eventTimes map[string][]time.Time
+}
+
+// A BrokerOption configures optional Broker behavior.
+type BrokerOption func(*Broker)
+
+// WithBufferSize overrides how many past events the broker replays to
+// a client reconnecting with a Last-Event-ID. Defaults to 1000.
+func WithBufferSize(n int) BrokerOption {
+	return func(b *Broker) {
+		b.bufferSize = n
+	}
+}
+
+// NewBroker creates a Broker, seeds it with the globally registered
+// forwarders, and starts its fan-out loop.
+func NewBroker(opts ...BrokerOption) *Broker {
+	b := &Broker{
+		newClients:     make(chan *subscription),
+		defunctClients: make(chan chan *deliveredEvent),
+		events:         make(chan *Event),
+		statsRequests:  make(chan chan *BrokerStats),
+		clients:        make(map[chan *deliveredEvent]*clientInfo),
+		bufferSize:     defaultBufferSize,
+		eventTimes:     make(map[string][]time.Time),
+	}
+	for _, f := range forwarders {
+		b.forwarders = append(b.forwarders, startForwarderWorker(f))
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	go b.Start()
+	return b
+}
+
+// Register adds a forwarder to this broker.
+func (b *Broker) Register(f Forwarder) {
+	if f == nil {
+		panic("Can't register nil forwarder")
+	}
+	b.mutex.Lock()
+	b.forwarders = append(b.forwarders, startForwarderWorker(f))
+	b.mutex.Unlock()
+}
+
+// Subscribe registers a new client for live events and returns a
+// channel to read them from, along with any buffered events carrying
+// a sequence ID greater than afterSeq to replay before switching to
+// that channel. remoteAddr and topics are recorded for display on the
+// admin clients page.
+func (b *Broker) Subscribe(afterSeq uint64, remoteAddr string, topics []string) (chan *deliveredEvent, []*deliveredEvent) {
+	ch := make(chan *deliveredEvent, clientSendBuffer)
+	replay := make(chan []*deliveredEvent, 1)
+	b.newClients <- &subscription{ch: ch, afterSeq: afterSeq, remoteAddr: remoteAddr, topics: topics, replay: replay}
+	return ch, <-replay
+}
+
+// Unsubscribe removes a client registered with Subscribe.
+func (b *Broker) Unsubscribe(ch chan *deliveredEvent) {
+	b.defunctClients <- ch
+}
+
+// Stats returns a snapshot of connected clients, event throughput and
+// queue depth, for the admin API.
+func (b *Broker) Stats() *BrokerStats {
+	reply := make(chan *BrokerStats, 1)
+	b.statsRequests <- reply
+	return <-reply
+}
+
+// Start runs the broker's fan-out loop. NewBroker starts this in its
+// own goroutine; callers constructing a Broker by hand must run it
+// themselves.
+func (b *Broker) Start() {
+	for {
+		select {
+		case sub := <-b.newClients:
+			sub.replay <- b.replayFrom(sub.afterSeq)
+			b.clients[sub.ch] = &clientInfo{
+				remoteAddr:  sub.remoteAddr,
+				topics:      sub.topics,
+				connectedAt: time.Now(),
+			}
+
+		case c := <-b.defunctClients:
+			// The client may already have been dropped by the
+			// fan-out loop below for falling behind, in which case
+			// it's already removed and closed.
+			if _, ok := b.clients[c]; ok {
+				delete(b.clients, c)
+				close(c)
+			}
+
+		case event := <-b.events:
+			b.nextSeq++
+			now := time.Now()
+
+			// Stamp "id"/"updatedAt" into a copy of the event's body
+			// rather than the shared map itself: event.Body is read
+			// concurrently by every forwarder's Forward call, so the
+			// original must stay untouched.
+			data := make(map[string]interface{}, len(event.Body)+2)
+			for k, v := range event.Body {
+				data[k] = v
+			}
+			data["id"] = event.ID
+			data["updatedAt"] = int32(now.Unix())
+
+			payload, err := json.Marshal(data)
+			if err != nil {
+				log.Printf("dashing: failed to marshal event %q: %v", event.ID, err)
+				continue
+			}
+
+			de := &deliveredEvent{
+				seqID:     b.nextSeq,
+				updatedAt: int32(now.Unix()),
+				event:     event,
+				payload:   payload,
+			}
+
+			b.buffer = append(b.buffer, de)
+			if len(b.buffer) > b.bufferSize {
+				b.buffer = b.buffer[len(b.buffer)-b.bufferSize:]
+			}
+			b.recordEventTime(event.ID, now)
+
+			// Deliver without blocking: a client that can't keep up
+			// with its buffer is disconnected rather than stalling
+			// every other client and job publish sharing this loop.
+			for c, info := range b.clients {
+				select {
+				case c <- de:
+					info.delivered++
+				default:
+					log.Printf("dashing: client %s can't keep up, disconnecting", info.remoteAddr)
+					delete(b.clients, c)
+					close(c)
+				}
+			}
+
+			// Forwarding happens off this goroutine too, via each
+			// forwarder's own worker and queue.
+			b.mutex.RLock()
+			for _, fw := range b.forwarders {
+				fw.send(event)
+			}
+			b.mutex.RUnlock()
+
+		case reply := <-b.statsRequests:
+			reply <- b.snapshot()
+		}
+	}
+}
+
+// recordEventTime appends a broadcast timestamp to id's history and
+// prunes entries older than the largest rate window. Must only be
+// called from the Start goroutine.
+func (b *Broker) recordEventTime(id string, at time.Time) {
+	cutoff := at.Add(-rateWindows[len(rateWindows)-1])
+	times := append(b.eventTimes[id], at)
+
+	i := 0
+	for ; i < len(times); i++ {
+		if times[i].After(cutoff) {
+			break
+		}
+	}
+	b.eventTimes[id] = times[i:]
+}
+
+// snapshot builds a BrokerStats from current broker state. Must only
+// be called from the Start goroutine.
+func (b *Broker) snapshot() *BrokerStats {
+	clients := make([]ClientStats, 0, len(b.clients))
+	for _, info := range b.clients {
+		clients = append(clients, ClientStats{
+			RemoteAddr:  info.remoteAddr,
+			Topics:      info.topics,
+			ConnectedAt: info.connectedAt,
+			Delivered:   info.delivered,
+		})
+	}
+
+	now := time.Now()
+	rates := make([]EventRate, 0, len(b.eventTimes))
+	for id, times := range b.eventTimes {
+		rate := EventRate{ID: id}
+		for _, t := range times {
+			switch {
+			case now.Sub(t) <= rateWindows[0]:
+				rate.Last1m++
+				rate.Last5m++
+				rate.Last15m++
+			case now.Sub(t) <= rateWindows[1]:
+				rate.Last5m++
+				rate.Last15m++
+			case now.Sub(t) <= rateWindows[2]:
+				rate.Last15m++
+			}
+		}
+		rates = append(rates, rate)
+	}
+
+	return &BrokerStats{
+		QueueDepth: len(b.events),
+		Clients:    clients,
+		EventRates: rates,
+	}
+}
+
+// replayFrom returns the buffered events with a sequence ID greater
+// than afterSeq, in broadcast order. The buffer is append-only and
+// sorted by sequence ID, so the start of the replay is found with a
+// binary search rather than a linear scan. Must only be called from
+// the Start goroutine.
+func (b *Broker) replayFrom(afterSeq uint64) []*deliveredEvent {
+	i := sort.Search(len(b.buffer), func(i int) bool {
+		return b.buffer[i].seqID > afterSeq
+	})
+	return append([]*deliveredEvent(nil), b.buffer[i:]...)
+}
+
+// Close shuts down every forwarder registered with this broker. It
+// drops them from b.forwarders before closing their queues, so that
+// any broadcast still in flight on the Start goroutine sees an empty
+// forwarder list rather than sending on an already-closed channel.
+func (b *Broker) Close() {
+	b.mutex.Lock()
+	fws := b.forwarders
+	b.forwarders = nil
+	b.mutex.Unlock()
+
+	for _, fw := range fws {
+		fw.close()
+	}
+}
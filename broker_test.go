@@ -0,0 +1,92 @@
+package dashing
+
+import (
+	"testing"
+	"time"
+)
+
+// testForwarder records every event handed to it, guarding access with
+// a channel since Forward is called from the forwarder's own worker
+// goroutine.
+type testForwarder struct {
+	forwarded chan *Event
+	closed    chan struct{}
+}
+
+func newTestForwarder() *testForwarder {
+	return &testForwarder{forwarded: make(chan *Event, 16), closed: make(chan struct{})}
+}
+
+func (f *testForwarder) Forward(e *Event) error {
+	f.forwarded <- e
+	return nil
+}
+
+func (f *testForwarder) Close() {
+	close(f.closed)
+}
+
+func TestBrokerSubscribeReplaysBufferedEvents(t *testing.T) {
+	b := NewBroker()
+	defer b.Close()
+
+	b.events <- &Event{ID: "a", Body: map[string]interface{}{"x": 1}, Target: "dashboards"}
+	b.Stats() // barrier: block until the broadcast above is fully processed
+
+	_, replay := b.Subscribe(0, "client1", nil)
+	if len(replay) != 1 || replay[0].event.ID != "a" {
+		t.Fatalf("replay = %+v, want one event with ID %q", replay, "a")
+	}
+
+	events, replay := b.Subscribe(replay[0].seqID, "client2", nil)
+	if len(replay) != 0 {
+		t.Fatalf("replay after latest seq = %+v, want none", replay)
+	}
+
+	b.events <- &Event{ID: "b", Body: map[string]interface{}{"y": 2}}
+
+	select {
+	case de := <-events:
+		if de.event.ID != "b" {
+			t.Fatalf("delivered event ID = %q, want %q", de.event.ID, "b")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for live event")
+	}
+}
+
+func TestBrokerForwardsToRegisteredForwarder(t *testing.T) {
+	b := NewBroker()
+	defer b.Close()
+
+	fwd := newTestForwarder()
+	b.Register(fwd)
+
+	b.events <- &Event{ID: "a", Body: map[string]interface{}{"x": 1}}
+
+	select {
+	case e := <-fwd.forwarded:
+		if e.ID != "a" {
+			t.Fatalf("forwarded event ID = %q, want %q", e.ID, "a")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for forwarded event")
+	}
+}
+
+func TestBrokerCloseStopsForwardingWithoutPanicking(t *testing.T) {
+	b := NewBroker()
+	b.Register(newTestForwarder())
+	b.Close()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("publishing after Close panicked: %v", r)
+		}
+	}()
+
+	// A broadcast after Close must not reach a forwarder whose queue
+	// was already closed.
+	b.events <- &Event{ID: "c", Body: map[string]interface{}{}}
+	b.Stats() // barrier: block until the broadcast above is fully processed
+}
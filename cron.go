@@ -0,0 +1,152 @@
+package dashing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldRange bounds the values accepted by one field of a cron
+// expression, in the standard "minute hour dom month dow" order.
+type fieldRange struct{ min, max int }
+
+var cronFieldRanges = [5]fieldRange{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 6},  // day of week (0 = Sunday)
+}
+
+// A Schedule is a parsed 5-field cron expression, used internally by
+// Worker to drive ScheduledJob, matching the field semantics of the
+// usual *nix crontab (and robfig/cron's standard parser) — including
+// its day-of-month/day-of-week special case: when both fields are
+// restricted (neither is "*"), a time matches if either one does,
+// rather than requiring both.
+type Schedule struct {
+	fields      [5]map[int]bool
+	domWildcard bool
+	dowWildcard bool
+}
+
+// ParseSchedule parses a standard 5-field cron expression: minute,
+// hour, day-of-month, month, day-of-week. Each field accepts "*",
+// "*/n", a single value, a comma-separated list, or an inclusive
+// range ("a-b", optionally stepped as "a-b/n").
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("dashing: cron expression %q must have 5 fields", expr)
+	}
+
+	var s Schedule
+	for i, field := range fields {
+		set, wildcard, err := parseCronField(field, cronFieldRanges[i].min, cronFieldRanges[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("dashing: cron expression %q: %v", expr, err)
+		}
+		s.fields[i] = set
+		switch i {
+		case 2:
+			s.domWildcard = wildcard
+		case 4:
+			s.dowWildcard = wildcard
+		}
+	}
+	return &s, nil
+}
+
+// parseCronField parses one field of a cron expression. wildcard
+// reports whether the field was exactly "*", which callers need to
+// implement cron's day-of-month/day-of-week OR special case.
+func parseCronField(field string, min, max int) (set map[int]bool, wildcard bool, err error) {
+	if field == "*" {
+		set = make(map[int]bool, max-min+1)
+		for v := min; v <= max; v++ {
+			set[v] = true
+		}
+		return set, true, nil
+	}
+
+	set = make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		value, step := part, 1
+
+		if idx := strings.IndexByte(part, '/'); idx >= 0 {
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return nil, false, fmt.Errorf("invalid step %q", part)
+			}
+			value = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case value == "*":
+			// lo/hi already cover the field's full range
+		case strings.Contains(value, "-"):
+			bounds := strings.SplitN(value, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, false, fmt.Errorf("invalid range %q", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, false, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			n, convErr := strconv.Atoi(value)
+			if convErr != nil {
+				return nil, false, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = n, n
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, false, nil
+}
+
+// Next returns the next minute-aligned time strictly after t that
+// matches the schedule. It searches minute by minute, which is more
+// than fast enough for a field that only needs to be evaluated once
+// per tick. The search covers 366 days; if no time in that span
+// matches (e.g. "0 0 30 2 *"), it returns the first minute after the
+// span without checking it.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for i := 0; i < 366*24*60; i++ {
+		if s.matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return t
+}
+
+func (s *Schedule) matches(t time.Time) bool {
+	if !s.fields[0][t.Minute()] || !s.fields[1][t.Hour()] || !s.fields[3][int(t.Month())] {
+		return false
+	}
+
+	dom := s.fields[2][t.Day()]
+	dow := s.fields[4][int(t.Weekday())]
+
+	// Standard cron special case: when both day-of-month and
+	// day-of-week are restricted, either one matching is enough.
+	// Otherwise the unrestricted field ("*") imposes no constraint.
+	switch {
+	case s.domWildcard && s.dowWildcard:
+		return true
+	case s.domWildcard:
+		return dow
+	case s.dowWildcard:
+		return dom
+	default:
+		return dom || dow
+	}
+}
@@ -0,0 +1,76 @@
+package dashing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNextEveryFiveMinutes(t *testing.T) {
+	s, err := ParseSchedule("*/5 * * * *")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+
+	from := time.Date(2026, time.July, 27, 10, 2, 0, 0, time.UTC)
+	next := s.Next(from)
+
+	want := time.Date(2026, time.July, 27, 10, 5, 0, 0, time.UTC)
+	if !next.Equal(want) {
+		t.Errorf("Next(%v) = %v, want %v", from, next, want)
+	}
+}
+
+func TestScheduleDayOfMonthAndDayOfWeekAreOred(t *testing.T) {
+	// "the 1st or 15th, or every Monday" - a classic crontab case
+	// where restricting both dom and dow must OR them together, not
+	// AND them.
+	s, err := ParseSchedule("0 0 1,15 * 1")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+
+	// Monday July 20 2026 is neither the 1st nor the 15th, but it is
+	// a Monday, so it must match.
+	monday := time.Date(2026, time.July, 20, 0, 0, 0, 0, time.UTC)
+	if !s.matches(monday) {
+		t.Errorf("expected %v (a Monday) to match", monday)
+	}
+
+	// Wednesday July 15 2026 is the 15th but not a Monday, so it
+	// must also match.
+	fifteenth := time.Date(2026, time.July, 15, 0, 0, 0, 0, time.UTC)
+	if !s.matches(fifteenth) {
+		t.Errorf("expected %v (the 15th) to match", fifteenth)
+	}
+
+	// Tuesday July 21 2026 is neither, so it must not match.
+	tuesday := time.Date(2026, time.July, 21, 0, 0, 0, 0, time.UTC)
+	if s.matches(tuesday) {
+		t.Errorf("expected %v to not match", tuesday)
+	}
+}
+
+func TestScheduleWildcardDayFieldsAreAnded(t *testing.T) {
+	// With both day fields wildcarded, only the explicit hour/minute
+	// constrain the match - the classic (and much more common) case.
+	s, err := ParseSchedule("30 9 * * *")
+	if err != nil {
+		t.Fatalf("ParseSchedule: %v", err)
+	}
+
+	match := time.Date(2026, time.July, 27, 9, 30, 0, 0, time.UTC)
+	if !s.matches(match) {
+		t.Errorf("expected %v to match", match)
+	}
+
+	noMatch := time.Date(2026, time.July, 27, 9, 31, 0, 0, time.UTC)
+	if s.matches(noMatch) {
+		t.Errorf("expected %v to not match", noMatch)
+	}
+}
+
+func TestParseScheduleRejectsWrongFieldCount(t *testing.T) {
+	if _, err := ParseSchedule("* * * *"); err == nil {
+		t.Error("expected an error for a 4-field expression")
+	}
+}
@@ -0,0 +1,11 @@
+package dashing
+
+// An Event carries a single update to be pushed to a dashboard or
+// widget. ID identifies the dashboard/widget the update belongs to,
+// Body is the raw JSON payload, and Target selects the SSE event name
+// (e.g. "dashboards") for dashboard-wide broadcasts.
+type Event struct {
+	ID     string
+	Body   map[string]interface{}
+	Target string
+}
@@ -0,0 +1,61 @@
+package dashing
+
+import (
+	"encoding/json"
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTForwarder publishes every broker event to an MQTT broker, one
+// topic per dashboard or widget ID, so that external systems (Home
+// Assistant, Node-RED, etc) can subscribe to the same stream without
+// scraping dashing's HTML.
+type MQTTForwarder struct {
+	client mqtt.Client
+	prefix string
+	qos    byte
+	retain bool
+}
+
+// NewMQTTForwarder connects to the MQTT broker at addr and returns a
+// Forwarder that publishes events as "<prefix>/<target>/<id>" (or
+// "<prefix>/<id>" for events with no target, such as widget events),
+// using the given QoS level and retain flag.
+func NewMQTTForwarder(addr, prefix string, qos byte, retain bool) (*MQTTForwarder, error) {
+	opts := mqtt.NewClientOptions().AddBroker(addr)
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &MQTTForwarder{
+		client: client,
+		prefix: prefix,
+		qos:    qos,
+		retain: retain,
+	}, nil
+}
+
+// Forward publishes the event's body to its MQTT topic.
+func (f *MQTTForwarder) Forward(e *Event) error {
+	data, err := json.Marshal(e.Body)
+	if err != nil {
+		return err
+	}
+
+	topic := f.prefix
+	if e.Target != "" {
+		topic = fmt.Sprintf("%s/%s", topic, e.Target)
+	}
+	topic = fmt.Sprintf("%s/%s", topic, e.ID)
+
+	token := f.client.Publish(topic, f.qos, f.retain, data)
+	token.Wait()
+	return token.Error()
+}
+
+// Close disconnects from the MQTT broker.
+func (f *MQTTForwarder) Close() {
+	f.client.Disconnect(250)
+}
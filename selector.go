@@ -0,0 +1,96 @@
+package dashing
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// selectorCache holds compiled selector regexps keyed by the selector
+// string, since selectorMatches is called once per selector per
+// connected client on every broadcast event and recompiling on each
+// call would be wasted work on that hot path.
+var selectorCache sync.Map // string -> *regexp.Regexp
+
+// eventTopic builds the canonical topic string for an event, used for
+// both topic-selector filtering and publish authorization. Dashboard
+// events carry their own target ("dashboards"); widget events have no
+// target and are bucketed under "widgets".
+func eventTopic(target, id string) string {
+	if target == "" {
+		target = "widgets"
+	}
+	return target + "/" + id
+}
+
+// selectorMatches reports whether topic is matched by selector.
+// Selectors support "*" as a glob (matching any run of characters,
+// including "/") and "{var}" URI template segments (matching exactly
+// one path segment), mirroring the selector syntax used by Mercure.
+func selectorMatches(selector, topic string) bool {
+	if selector == topic || selector == "*" {
+		return true
+	}
+
+	re, err := compileSelector(selector)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(topic)
+}
+
+// matchesAny reports whether topic matches at least one of selectors.
+func matchesAny(selectors []string, topic string) bool {
+	for _, selector := range selectors {
+		if selectorMatches(selector, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileSelector compiles selector into a regexp, reusing a
+// previously compiled one for the same selector string if there is one.
+func compileSelector(selector string) (*regexp.Regexp, error) {
+	if cached, ok := selectorCache.Load(selector); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := buildSelectorRegexp(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	if actual, loaded := selectorCache.LoadOrStore(selector, re); loaded {
+		return actual.(*regexp.Regexp), nil
+	}
+	return re, nil
+}
+
+func buildSelectorRegexp(selector string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(selector); {
+		switch c := selector[i]; c {
+		case '*':
+			b.WriteString(".*")
+			i++
+		case '{':
+			end := strings.IndexByte(selector[i:], '}')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(selector[i:]))
+				i = len(selector)
+				continue
+			}
+			b.WriteString("[^/]+")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			i++
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
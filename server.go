@@ -7,11 +7,10 @@ import (
 	"net/http"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"sync"
-
 	"gopkg.in/husobee/vestigo.v1"
 	"gopkg.in/karlseguin/gerb.v0"
 )
@@ -21,7 +20,11 @@ type Server struct {
 	dev     bool
 	webroot string
 	broker  *Broker
-	mutex   sync.RWMutex
+	auth    *Authorizer
+
+	worker    *Worker
+	adminUser string
+	adminPass string
 }
 
 func param(r *http.Request, name string) string {
@@ -57,19 +60,32 @@ func (s *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create a new channel, over which the broker can
-	// send this client events.
-	events := make(chan *Event)
+	var permitted []string
+	if s.auth != nil {
+		p, err := s.auth.Subscribed(r)
+		if err != nil {
+			http.Error(w, "", http.StatusUnauthorized)
+			return
+		}
+		permitted = p
+	}
+	requested := r.URL.Query()["topic"]
 
-	// Add this client to the map of those that should
-	// receive updates
-	s.broker.newClients <- events
+	allowed := func(de *deliveredEvent) bool {
+		topic := eventTopic(de.event.Target, de.event.ID)
+		if s.auth != nil && !matchesAny(permitted, topic) {
+			return false
+		}
+		if len(requested) > 0 && !matchesAny(requested, topic) {
+			return false
+		}
+		return true
+	}
 
-	// Remove this client from the map of attached clients
-	// when the handler exits.
-	defer func() {
-		s.broker.defunctClients <- events
-	}()
+	// Register this client with the broker, replaying any buffered
+	// events since its Last-Event-ID before tailing live events.
+	events, replay := s.broker.Subscribe(lastEventID(r), r.RemoteAddr, requested)
+	defer s.broker.Unsubscribe(events)
 
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
@@ -77,22 +93,31 @@ func (s *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Accel-Buffering", "no")
 	closer := c.CloseNotify()
 
+	for _, de := range replay {
+		if allowed(de) {
+			s.writeEvent(w, de)
+			f.Flush()
+		}
+	}
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
 	for {
 		select {
-		case event := <-events:
-			s.mutex.Lock()
-			data := event.Body
-			data["id"] = event.ID
-			data["updatedAt"] = int32(time.Now().Unix())
-			json, err := json.Marshal(data)
-			if err != nil {
-				continue
+		case de, ok := <-events:
+			if !ok {
+				// The broker closed our channel because we fell too
+				// far behind to keep up; drop the connection.
+				return
 			}
-			s.mutex.Unlock()
-			if event.Target != "" {
-				fmt.Fprintf(w, "event: %s\n", event.Target)
+			if !allowed(de) {
+				continue
 			}
-			fmt.Fprintf(w, "data: %s\n\n", json)
+			s.writeEvent(w, de)
+			f.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
 			f.Flush()
 		case <-closer:
 			return
@@ -100,6 +125,31 @@ func (s *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// lastEventID reads the client's Last-Event-ID, preferring the SSE
+// reconnection header and falling back to a ?lastEventID= query
+// parameter for clients that can't set custom headers (e.g. EventSource
+// polyfills behind some proxies).
+func lastEventID(r *http.Request) uint64 {
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("lastEventID")
+	}
+	seq, _ := strconv.ParseUint(id, 10, 64)
+	return seq
+}
+
+// writeEvent writes a single delivered event to the SSE stream,
+// stamping it with the "id:" line clients echo back as Last-Event-ID
+// on reconnect. de.payload is already-marshaled JSON, precomputed once
+// per broadcast by the broker rather than per subscriber.
+func (s *Server) writeEvent(w http.ResponseWriter, de *deliveredEvent) {
+	if de.event.Target != "" {
+		fmt.Fprintf(w, "event: %s\n", de.event.Target)
+	}
+	fmt.Fprintf(w, "id: %d\n", de.seqID)
+	fmt.Fprintf(w, "data: %s\n\n", de.payload)
+}
+
 // DashboardHandler serves the dashboard layout template.
 func (s *Server) DashboardHandler(w http.ResponseWriter, r *http.Request) {
 	dashboard := param(r, "dashboard")
@@ -130,6 +180,14 @@ func (s *Server) DashboardEventHandler(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 	}
 
+	id := param(r, "id")
+	if s.auth != nil {
+		if err := s.auth.AuthorizePublish(r, "dashboards", id); err != nil {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+	}
+
 	var data map[string]interface{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -137,7 +195,7 @@ func (s *Server) DashboardEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.broker.events <- &Event{param(r, "id"), data, "dashboards"}
+	s.broker.events <- &Event{id, data, "dashboards"}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -167,6 +225,14 @@ func (s *Server) WidgetEventHandler(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 	}
 
+	id := param(r, "id")
+	if s.auth != nil {
+		if err := s.auth.AuthorizePublish(r, "", id); err != nil {
+			http.Error(w, "", http.StatusForbidden)
+			return
+		}
+	}
+
 	var data map[string]interface{}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
@@ -175,7 +241,7 @@ func (s *Server) WidgetEventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.broker.events <- &Event{param(r, "id"), data, ""}
+	s.broker.events <- &Event{id, data, ""}
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -191,6 +257,15 @@ func (s *Server) NewRouter(gets, posts map[string]http.HandlerFunc) *vestigo.Rou
 	r.Get("/views/:widget", s.WidgetHandler)
 	r.Post("/widgets/:id", s.WidgetEventHandler)
 
+	if s.worker != nil {
+		r.Get("/admin/jobs", s.requireAdmin(s.AdminJobsHandler))
+		r.Get("/admin/clients", s.requireAdmin(s.AdminClientsHandler))
+		r.Get("/admin/events", s.requireAdmin(s.AdminEventsHandler))
+		r.Get("/admin/metrics", s.requireAdmin(s.AdminMetricsHandler))
+		r.Post("/admin/jobs/:id/trigger", s.requireAdmin(s.AdminTriggerHandler))
+		r.Get("/_status", s.requireAdmin(s.AdminStatusHandler))
+	}
+
 	for route, handler := range gets {
 		r.Get(route, handler)
 	}
@@ -207,11 +282,56 @@ func (s *Server) NewRouter(gets, posts map[string]http.HandlerFunc) *vestigo.Rou
 	return r
 }
 
-// NewServer creates a Server instance.
-func NewServer(b *Broker, webroot string) *Server {
-	return &Server{
+// A ServerOption configures optional Server behavior.
+type ServerOption func(*Server)
+
+// WithForwarders registers the given forwarders with the broker so the
+// SSE stream's events are also relayed to them.
+func WithForwarders(forwarders ...Forwarder) ServerOption {
+	return func(s *Server) {
+		for _, f := range forwarders {
+			s.broker.Register(f)
+		}
+	}
+}
+
+// WithAuth gates subscription (on /events) and publication (on the
+// dashboard and widget event endpoints) with JWT bearer tokens, using
+// the given signing keys, the selectors anonymous clients may
+// subscribe to, and the claim namespace selectors are read from.
+func WithAuth(keys map[string]interface{}, anonymousSubscribe []string, namespace string) ServerOption {
+	return func(s *Server) {
+		s.auth = &Authorizer{
+			Keys:               keys,
+			Namespace:          namespace,
+			AnonymousSubscribe: anonymousSubscribe,
+		}
+	}
+}
+
+// WithAdmin mounts the /admin/* API and the /_status HTML page,
+// protected by HTTP Basic Auth with the given credentials or, if user
+// is empty, by the JWT scheme configured with WithAuth (a token must
+// cover the "admin/status" topic to publish).
+func WithAdmin(worker *Worker, user, pass string) ServerOption {
+	return func(s *Server) {
+		s.worker = worker
+		s.adminUser = user
+		s.adminPass = pass
+	}
+}
+
+// NewServer creates a Server instance and applies any options.
+func NewServer(b *Broker, webroot string, opts ...ServerOption) *Server {
+	s := &Server{
 		dev:     false,
 		webroot: webroot,
 		broker:  b,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
@@ -0,0 +1,148 @@
+package dashing
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// wsWriteTimeout bounds how long a single WriteMessage call may block,
+// so one stalled client can't hold up delivery to every other client.
+const wsWriteTimeout = 10 * time.Second
+
+// wsSendBuffer is how many undelivered events a client's queue holds
+// before new events are dropped rather than blocking the forwarder.
+const wsSendBuffer = 16
+
+// wsClient relays events to one connected WebSocket client through its
+// own queue and goroutine, the same drop-on-backpressure approach
+// forwarderWorker uses for a whole Forwarder, so a stalled client can't
+// block delivery to the rest of this forwarder's clients.
+type wsClient struct {
+	conn   *websocket.Conn
+	events chan []byte
+}
+
+func newWSClient(conn *websocket.Conn) *wsClient {
+	c := &wsClient{conn: conn, events: make(chan []byte, wsSendBuffer)}
+	go c.run()
+	return c
+}
+
+func (c *wsClient) run() {
+	for data := range c.events {
+		c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+}
+
+// send enqueues data for delivery, dropping it if the client is too
+// far behind to keep up rather than blocking the forwarder.
+func (c *wsClient) send(data []byte) {
+	select {
+	case c.events <- data:
+	default:
+		log.Printf("dashing: websocket client can't keep up, dropping event")
+	}
+}
+
+func (c *wsClient) close() {
+	close(c.events)
+	c.conn.Close()
+}
+
+// WebSocketForwarder relays every broker event to connected WebSocket
+// clients, for deployments where SSE connections can't be held open
+// through some proxies. Wire Handler into a route (e.g. via the gets
+// map passed to Server.NewRouter) to accept connections.
+type WebSocketForwarder struct {
+	mutex   sync.RWMutex
+	clients map[*websocket.Conn]*wsClient
+}
+
+// NewWebSocketForwarder returns an empty WebSocketForwarder.
+func NewWebSocketForwarder() *WebSocketForwarder {
+	return &WebSocketForwarder{clients: make(map[*websocket.Conn]*wsClient)}
+}
+
+// Handler upgrades the connection to a WebSocket and registers it to
+// receive forwarded events until the client disconnects.
+func (f *WebSocketForwarder) Handler(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("dashing: websocket upgrade failed: %v", err)
+		return
+	}
+
+	client := newWSClient(conn)
+	f.mutex.Lock()
+	f.clients[conn] = client
+	f.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			f.mutex.Lock()
+			delete(f.clients, conn)
+			f.mutex.Unlock()
+			client.close()
+		}()
+
+		// Drain and discard client frames; this connection is
+		// read-only from the client's perspective. Returns once the
+		// client disconnects.
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// wsPayload is the wire format written to WebSocket clients. Unlike the
+// MQTT forwarder, where the dashboard/widget ID is encoded in the
+// topic, a WebSocket connection is a single multiplexed stream, so ID
+// and Target have to travel alongside the body for a subscriber to
+// tell which dashboard or widget an event belongs to.
+type wsPayload struct {
+	ID     string                 `json:"id"`
+	Target string                 `json:"target,omitempty"`
+	Body   map[string]interface{} `json:"body"`
+}
+
+// Forward queues the event, stamped with its ID and target, for
+// delivery to every connected client.
+func (f *WebSocketForwarder) Forward(e *Event) error {
+	data, err := json.Marshal(wsPayload{ID: e.ID, Target: e.Target, Body: e.Body})
+	if err != nil {
+		return err
+	}
+
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	for _, client := range f.clients {
+		client.send(data)
+	}
+	return nil
+}
+
+// Close disconnects every connected client.
+func (f *WebSocketForwarder) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for conn, client := range f.clients {
+		client.close()
+		delete(f.clients, conn)
+	}
+}
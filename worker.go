@@ -1,15 +1,71 @@
 package dashing
 
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
 // A Job does periodic work and sends events to a channel.
 type Job interface {
 	Work(config *Config, send chan *Event)
 }
 
+// A NamedJob is a Job that can report its own name for display in the
+// admin UI. Jobs that don't implement it are listed by their Go type.
+type NamedJob interface {
+	Job
+	Name() string
+}
+
+// A ScheduledJob is run by Worker on a cron schedule, once per
+// matching tick, instead of once at startup. Unlike a plain Job, each
+// call to Work is expected to do one run and return.
+type ScheduledJob interface {
+	Job
+	Schedule() string
+}
+
+// A TriggerableJob can be identified by ID and run on demand via
+// POST /admin/jobs/{id}/trigger, in addition to any schedule it has.
+type TriggerableJob interface {
+	Job
+	ID() string
+}
+
+// A JitterJob adds up to Jitter() of random delay after each scheduled
+// tick, to spread out jobs that would otherwise fire at the same instant.
+type JitterJob interface {
+	Job
+	Jitter() time.Duration
+}
+
+// A SkipIfRunningJob is skipped on a scheduled tick if its previous
+// run hasn't finished yet, instead of running concurrently with it.
+type SkipIfRunningJob interface {
+	Job
+	SkipIfRunning() bool
+}
+
+// JobStatus is a point-in-time snapshot of a job's execution state,
+// exposed by Worker.Status for the admin API.
+type JobStatus struct {
+	Name        string
+	LastRun     time.Time
+	LastError   error
+	LastPayload *Event
+}
+
 // A Worker contains a collection of jobs.
 type Worker struct {
 	broker   *Broker
 	config   *Config
 	registry []Job
+
+	mutex       sync.RWMutex
+	statuses    map[string]*JobStatus
+	triggerable map[string]Job
 }
 
 // Register a job for a particular worker.
@@ -20,20 +76,169 @@ func (w *Worker) Register(j Job) {
 	w.registry = append(w.registry, j)
 }
 
-// Start all jobs.
+// Start all jobs. Jobs implementing ScheduledJob run on their cron
+// schedule; all others keep the original "run once, manage yourself"
+// behavior. Every run is wrapped with panic recovery and last-run
+// bookkeeping.
 func (w *Worker) Start() {
 	for _, j := range w.registry {
-		go j.Work(w.config, w.broker.events)
+		w.start(j)
 	}
 }
 
+func (w *Worker) start(j Job) {
+	name := jobName(j)
+
+	w.mutex.Lock()
+	if _, ok := w.statuses[name]; !ok {
+		w.statuses[name] = &JobStatus{Name: name}
+	}
+	if tj, ok := j.(TriggerableJob); ok {
+		if w.triggerable == nil {
+			w.triggerable = make(map[string]Job)
+		}
+		w.triggerable[tj.ID()] = j
+	}
+	w.mutex.Unlock()
+
+	if sj, ok := j.(ScheduledJob); ok {
+		schedule, err := ParseSchedule(sj.Schedule())
+		if err != nil {
+			w.recordError(name, err)
+			return
+		}
+		go w.runScheduled(j, name, schedule)
+		return
+	}
+
+	go w.runWork(j, name)
+}
+
+// runScheduled sleeps until each tick of schedule (plus jitter, if j
+// is a JitterJob) and runs j.Work once per tick. If j is a
+// SkipIfRunningJob, a tick is skipped entirely when the previous run
+// is still in flight rather than overlapping with it.
+func (w *Worker) runScheduled(j Job, name string, schedule *Schedule) {
+	var jitter time.Duration
+	if jj, ok := j.(JitterJob); ok {
+		jitter = jj.Jitter()
+	}
+
+	skipIfRunning := false
+	if sj, ok := j.(SkipIfRunningJob); ok {
+		skipIfRunning = sj.SkipIfRunning()
+	}
+
+	idle := make(chan struct{}, 1)
+	idle <- struct{}{}
+
+	for {
+		next := schedule.Next(time.Now())
+		if jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(jitter))))
+		}
+		time.Sleep(time.Until(next))
+
+		if !skipIfRunning {
+			go w.runWork(j, name)
+			continue
+		}
+
+		select {
+		case <-idle:
+			go func() {
+				defer func() { idle <- struct{}{} }()
+				w.runWork(j, name)
+			}()
+		default:
+			// Previous run is still in flight; skip this tick.
+		}
+	}
+}
+
+// Trigger runs the TriggerableJob with the given ID once, out-of-band
+// from any schedule it has. It returns an error if no such job is
+// registered.
+func (w *Worker) Trigger(id string) error {
+	w.mutex.RLock()
+	job, ok := w.triggerable[id]
+	w.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("dashing: no triggerable job registered with id %q", id)
+	}
+
+	go w.runWork(job, jobName(job))
+	return nil
+}
+
+// runWork invokes j.Work once, recovering any panic and relaying every
+// event it sends to the broker while recording the job's last-run
+// bookkeeping.
+func (w *Worker) runWork(j Job, name string) {
+	send := make(chan *Event)
+
+	go func() {
+		for event := range send {
+			w.recordRun(name, event)
+			w.broker.events <- event
+		}
+	}()
+
+	defer close(send)
+	defer func() {
+		if r := recover(); r != nil {
+			w.recordError(name, fmt.Errorf("panic: %v", r))
+		}
+	}()
+	j.Work(w.config, send)
+}
+
+func (w *Worker) recordRun(name string, event *Event) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	status := w.statuses[name]
+	status.LastRun = time.Now()
+	status.LastPayload = event
+}
+
+func (w *Worker) recordError(name string, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.statuses[name].LastError = err
+}
+
+// Status returns a snapshot of every registered job's last-run
+// bookkeeping, for the admin API.
+func (w *Worker) Status() []JobStatus {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	out := make([]JobStatus, 0, len(w.statuses))
+	for _, status := range w.statuses {
+		out = append(out, *status)
+	}
+	return out
+}
+
+func jobName(j Job) string {
+	if named, ok := j.(NamedJob); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", j)
+}
+
 // NewWorker returns a Worker instance.
 func NewWorker(b *Broker, c *Config) *Worker {
-	return &Worker{
+	w := &Worker{
 		broker:   b,
 		config:   c,
 		registry: append([]Job(nil), jobs...),
+		statuses: make(map[string]*JobStatus),
+	}
+	for _, j := range w.registry {
+		w.statuses[jobName(j)] = &JobStatus{Name: jobName(j)}
 	}
+	return w
 }
 
 // Global registry for background jobs.